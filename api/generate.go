@@ -0,0 +1,10 @@
+package api
+
+// Regenerate the protobuf message types declared in gorsh.proto once
+// protoc and protoc-gen-go are available on PATH:
+//
+//go:generate protoc --go_out=. --go_opt=paths=source_relative gorsh.proto
+//
+// Until then, rpcapi hand-implements the service contract described here
+// (see rpcapi/rpcapi.go) against a JSON wire codec rather than generated
+// protobuf bindings.
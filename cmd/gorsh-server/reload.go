@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// drainWG is held for the whole of every in-flight handleImplant call
+// (including its proxyConnToSocket) so a graceful shutdown/reload can wait
+// for shells to close on their own.
+var drainWG sync.WaitGroup
+
+// boundTCPListener is the raw TCP listener bound by newRawListener or
+// reconstructed by inheritedListener, underneath the cmux/TLS stack.
+// Neither cmux nor crypto/tls exposes a way to recover it from the listeners
+// they wrap it in, so we stash it here when we create it, for reexec to
+// later pull a file descriptor out of.
+var boundTCPListener *net.TCPListener
+
+// listenerFDEnv carries the inherited listener's fd number across reexec.
+//
+// Only the listening socket is handed off. An implant connection can't be:
+// its TLS session state (negotiated keys, record sequence numbers) lives
+// only in this process's *tls.Conn and isn't recoverable from a bare fd,
+// and what's flowing over it is yamux frames, not a raw shell byte stream,
+// so a successor reading it directly would desync immediately even if
+// decryption weren't a problem. So every implant already connected to this
+// process keeps talking to this process: under SIGUSR2 that just means
+// this process keeps serving them alongside the successor; under SIGHUP,
+// gracefulDrain waits for them to finish on their own before this process
+// exits.
+const listenerFDEnv = "GORSH_LISTENER_FD"
+
+// installSignalHandlers wires SIGUSR2/SIGHUP into a fork-and-inherit-listener
+// reload, SIGTERM/SIGINT into a graceful drain, and SIGQUIT into an
+// immediate exit. Only meaningful in shell-catching mode, since that's the
+// process holding the listener and implant connections.
+func installSignalHandlers(listener net.Listener) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR2, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+
+	go func() {
+		for sig := range sigCh {
+			switch sig {
+			case syscall.SIGUSR2:
+				log.Info("SIGUSR2 received, reloading in place")
+				if err := reexec(); err != nil {
+					log.WithError(err).Error("reload failed, continuing with current process")
+				}
+
+			case syscall.SIGHUP:
+				log.Info("SIGHUP received, reloading and draining this process")
+				if err := reexec(); err != nil {
+					log.WithError(err).Error("reload failed, continuing with current process")
+					continue
+				}
+				gracefulDrain(listener)
+
+			case syscall.SIGTERM, syscall.SIGINT:
+				log.Info("shutdown signal received, draining active shells")
+				gracefulDrain(listener)
+
+			case syscall.SIGQUIT:
+				log.Warn("SIGQUIT received, exiting immediately")
+				os.Exit(0)
+			}
+		}
+	}()
+}
+
+// gracefulDrain stops accepting new implants and waits for every in-flight
+// shell to close before exiting. See listenerFDEnv: that's also how a
+// reexec'd successor's new connections are kept separate from this
+// process's existing ones.
+func gracefulDrain(listener net.Listener) {
+	listener.Close()
+	drainWG.Wait()
+	os.Exit(0)
+}
+
+// reexec forks a copy of this binary, handing it only the listener fd so it
+// can accept new implant connections. See listenerFDEnv for why existing
+// implant connections aren't, and can't be, handed off too.
+func reexec() error {
+	if boundTCPListener == nil {
+		return fmt.Errorf("reexec: no raw TCP listener on record")
+	}
+	listenerFile, err := boundTCPListener.File()
+	if err != nil {
+		return fmt.Errorf("listener fd: %w", err)
+	}
+
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr, listenerFile}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("os.Executable: %w", err)
+	}
+
+	proc, err := os.StartProcess(self, os.Args, &os.ProcAttr{
+		Files: files,
+		Env:   append(os.Environ(), fmt.Sprintf("%s=%d", listenerFDEnv, len(files)-1)),
+	})
+	if err != nil {
+		return fmt.Errorf("StartProcess: %w", err)
+	}
+
+	log.WithField("pid", proc.Pid).Info("spawned successor process")
+	return nil
+}
+
+// inheritedListener reconstructs the raw, bound TCP listener from
+// listenerFDEnv if this process was started by reexec. It returns a nil
+// listener (and nil error) if the env var isn't set. The caller is
+// responsible for wrapping it in the cmux/TLS stack (see newMultiplexer).
+func inheritedListener() (net.Listener, error) {
+	fdStr, ok := os.LookupEnv(listenerFDEnv)
+	if !ok {
+		return nil, nil
+	}
+
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		return nil, fmt.Errorf("bad %s %q: %w", listenerFDEnv, fdStr, err)
+	}
+
+	rawListener, err := net.FileListener(os.NewFile(uintptr(fd), "listener"))
+	if err != nil {
+		return nil, fmt.Errorf("FileListener: %w", err)
+	}
+	tcpListener, ok := rawListener.(*net.TCPListener)
+	if !ok {
+		return nil, fmt.Errorf("inherited listener is not a *net.TCPListener")
+	}
+	boundTCPListener = tcpListener
+	return tcpListener, nil
+}
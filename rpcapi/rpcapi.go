@@ -0,0 +1,287 @@
+// Package rpcapi implements the gRPC service declared in api/gorsh.proto,
+// backing gorshctl so operators can script gorsh without shelling out
+// through tmux.
+//
+// No protoc/protoc-gen-go-grpc toolchain is vendored into this repo yet, so
+// rather than commit hand-faked "generated" protobuf bindings, the service
+// and its messages are implemented directly against google.golang.org/grpc
+// using a small JSON wire codec. Swap this file for real generated stubs
+// (see api/generate.go) once that toolchain is available; the RPC surface
+// and method names are already kept in lockstep with the .proto so that
+// swap is mechanical.
+package rpcapi
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+const serviceName = "gorsh.GorshService"
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec lets the client and server exchange plain Go structs as JSON
+// instead of protobuf wire format.
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                               { return "json" }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// CallOption selects the JSON codec on the client side; pass it to every
+// ClientConn.Invoke/NewStream call made against this service.
+func CallOption() grpc.CallOption { return grpc.CallContentSubtype(jsonCodec{}.Name()) }
+
+// ServerOption selects the JSON codec on the server side; pass it to
+// grpc.NewServer before calling RegisterGorshServer.
+func ServerOption() grpc.ServerOption { return grpc.ForceServerCodec(jsonCodec{}) }
+
+type SessionInfo struct {
+	Hostname string   `json:"hostname"`
+	Windows  []string `json:"windows"`
+}
+
+type ListSessionsRequest struct{}
+
+type ListSessionsResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+type KillWindowRequest struct {
+	Hostname string `json:"hostname"`
+	Window   string `json:"window"`
+}
+
+type KillWindowResponse struct{}
+
+type SendInputRequest struct {
+	Hostname string `json:"hostname"`
+	Window   string `json:"window"`
+	Data     []byte `json:"data"`
+}
+
+type SendInputResponse struct{}
+
+type UploadRequest struct {
+	Hostname string `json:"hostname"`
+	Remote   string `json:"remote"`
+	Data     []byte `json:"data"`
+}
+
+type UploadResponse struct{}
+
+type DownloadRequest struct {
+	Hostname string `json:"hostname"`
+	Remote   string `json:"remote"`
+}
+
+type DownloadResponse struct {
+	Data []byte `json:"data"`
+}
+
+type TailRequest struct {
+	Hostname string `json:"hostname"`
+	Window   string `json:"window"`
+}
+
+type TailResponse struct {
+	Data []byte `json:"data"`
+}
+
+// Server is implemented by gorsh-server to back the RPCs declared in
+// api/gorsh.proto.
+type Server interface {
+	ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error)
+	KillWindow(context.Context, *KillWindowRequest) (*KillWindowResponse, error)
+	SendInput(context.Context, *SendInputRequest) (*SendInputResponse, error)
+	Upload(context.Context, *UploadRequest) (*UploadResponse, error)
+	Download(context.Context, *DownloadRequest) (*DownloadResponse, error)
+	Tail(*TailRequest, Gorsh_TailServer) error
+}
+
+// Gorsh_TailServer is the server-side handle for the streaming Tail RPC.
+type Gorsh_TailServer interface {
+	Send(*TailResponse) error
+	grpc.ServerStream
+}
+
+type gorshTailServer struct{ grpc.ServerStream }
+
+func (s *gorshTailServer) Send(m *TailResponse) error { return s.ServerStream.SendMsg(m) }
+
+// RegisterGorshServer wires srv into s under the GorshService name.
+func RegisterGorshServer(s *grpc.Server, srv Server) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*Server)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "ListSessions", Handler: listSessionsHandler},
+		{MethodName: "KillWindow", Handler: killWindowHandler},
+		{MethodName: "SendInput", Handler: sendInputHandler},
+		{MethodName: "Upload", Handler: uploadHandler},
+		{MethodName: "Download", Handler: downloadHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Tail", Handler: tailHandler, ServerStreams: true},
+	},
+	Metadata: "api/gorsh.proto",
+}
+
+func listSessionsHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Server).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/ListSessions"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Server).ListSessions(ctx, req.(*ListSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func killWindowHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KillWindowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Server).KillWindow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/KillWindow"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Server).KillWindow(ctx, req.(*KillWindowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func sendInputHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendInputRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Server).SendInput(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/SendInput"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Server).SendInput(ctx, req.(*SendInputRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func uploadHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Server).Upload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/Upload"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Server).Upload(ctx, req.(*UploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func downloadHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DownloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Server).Download(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/Download"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Server).Download(ctx, req.(*DownloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func tailHandler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TailRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(Server).Tail(m, &gorshTailServer{stream})
+}
+
+// Client is a thin hand-written stub standing in for what
+// protoc-gen-go-grpc would generate.
+type Client struct {
+	cc *grpc.ClientConn
+}
+
+func NewClient(cc *grpc.ClientConn) *Client { return &Client{cc: cc} }
+
+func (c *Client) ListSessions(ctx context.Context, in *ListSessionsRequest) (*ListSessionsResponse, error) {
+	out := new(ListSessionsResponse)
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/ListSessions", in, out, CallOption())
+	return out, err
+}
+
+func (c *Client) KillWindow(ctx context.Context, in *KillWindowRequest) (*KillWindowResponse, error) {
+	out := new(KillWindowResponse)
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/KillWindow", in, out, CallOption())
+	return out, err
+}
+
+func (c *Client) SendInput(ctx context.Context, in *SendInputRequest) (*SendInputResponse, error) {
+	out := new(SendInputResponse)
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/SendInput", in, out, CallOption())
+	return out, err
+}
+
+func (c *Client) Upload(ctx context.Context, in *UploadRequest) (*UploadResponse, error) {
+	out := new(UploadResponse)
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/Upload", in, out, CallOption())
+	return out, err
+}
+
+func (c *Client) Download(ctx context.Context, in *DownloadRequest) (*DownloadResponse, error) {
+	out := new(DownloadResponse)
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/Download", in, out, CallOption())
+	return out, err
+}
+
+func (c *Client) Tail(ctx context.Context, in *TailRequest) (Gorsh_TailClient, error) {
+	stream, err := c.cc.NewStream(ctx, &serviceDesc.Streams[0], "/"+serviceName+"/Tail", CallOption())
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &gorshTailClient{stream}, nil
+}
+
+// Gorsh_TailClient is the client-side handle for the streaming Tail RPC.
+type Gorsh_TailClient interface {
+	Recv() (*TailResponse, error)
+	grpc.ClientStream
+}
+
+type gorshTailClient struct{ grpc.ClientStream }
+
+func (c *gorshTailClient) Recv() (*TailResponse, error) {
+	m := new(TailResponse)
+	if err := c.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,128 @@
+// Package session owns gorsh's live implant/tmux session state so both the
+// raw TCP accept path and the gRPC API in rpcapi can mutate it safely.
+package session
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/clayne/gorsh/transport"
+	"github.com/disneystreaming/gomux"
+	log "github.com/sirupsen/logrus"
+)
+
+// Info is everything gorsh knows about one implant: the tmux session it's
+// piped into, the yamux mux backing it for on-demand upload/download/
+// socks/exec streams, and enough bookkeeping to answer a ListSessions call.
+type Info struct {
+	*gomux.Session
+	Mux *transport.Session
+
+	// Windows lists the tmux window ids opened for this host, minus any
+	// dropped by RemoveWindow.
+	Windows []string
+
+	LastUsername string
+	LastSocket   string
+}
+
+func (i *Info) String() string {
+	return fmt.Sprintf("%s (%d windows)", i.Name, i.NextWindowNumber)
+}
+
+// Manager is the single source of truth for every live implant session.
+// It's safe for concurrent use by the accept loop, the admin socket
+// handlers and the gRPC API.
+type Manager struct {
+	mu       sync.Mutex
+	sessions map[string]*Info
+}
+
+func NewManager() *Manager {
+	return &Manager{sessions: make(map[string]*Info)}
+}
+
+// Register finds or creates the tmux session for hostname and attaches the
+// freshly-established yamux mux to it.
+func (m *Manager) Register(hostname string, mux *transport.Session) (*Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sessions[hostname] == nil {
+		exists, err := gomux.CheckSessionExists(hostname)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			log.WithField("host", hostname).Info("new host connected, creating session")
+			gs, err := gomux.NewSession(hostname)
+			if err != nil {
+				log.Warn(err)
+			}
+			m.sessions[hostname] = &Info{Session: gs}
+		} else {
+			log.WithField("host", hostname).Debug("creating new cached session")
+			m.sessions[hostname] = &Info{Session: &gomux.Session{Name: hostname}}
+		}
+	}
+
+	m.sessions[hostname].Mux = mux
+	return m.sessions[hostname], nil
+}
+
+// Get returns the session for hostname, or nil if there isn't one.
+func (m *Manager) Get(hostname string) *Info {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sessions[hostname]
+}
+
+// RecordWindow notes a newly opened tmux window against hostname's session.
+func (m *Manager) RecordWindow(hostname, username, sockPath, windowID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info := m.sessions[hostname]
+	if info == nil {
+		return
+	}
+	info.LastUsername = username
+	info.LastSocket = sockPath
+	info.Windows = append(info.Windows, windowID)
+}
+
+// RemoveWindow drops windowID from hostname's session after it's been
+// killed, so ListSessions stops reporting it as live.
+func (m *Manager) RemoveWindow(hostname, windowID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info := m.sessions[hostname]
+	if info == nil {
+		return
+	}
+	kept := info.Windows[:0]
+	for _, w := range info.Windows {
+		if w != windowID {
+			kept = append(kept, w)
+		}
+	}
+	info.Windows = kept
+}
+
+// All returns a snapshot of every live session, keyed by hostname. Each Info
+// is a copy with its own Windows slice, so callers can read it without racing
+// RecordWindow/RemoveWindow; Session and Mux still point at the live objects.
+func (m *Manager) All() map[string]*Info {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[string]*Info, len(m.sessions))
+	for k, v := range m.sessions {
+		c := *v
+		c.Windows = append([]string(nil), v.Windows...)
+		out[k] = &c
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/disneystreaming/gomux"
+)
+
+// put installs info directly, bypassing Register's tmux round trip, so
+// tests can exercise the rest of Manager without a real tmux binary.
+func put(m *Manager, hostname string, info *Info) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[hostname] = info
+}
+
+func TestGetMissing(t *testing.T) {
+	m := NewManager()
+	if got := m.Get("nope"); got != nil {
+		t.Fatalf("Get() on empty Manager = %v, want nil", got)
+	}
+}
+
+func TestRecordWindowNoSession(t *testing.T) {
+	m := NewManager()
+	// Must not panic when the hostname isn't registered yet.
+	m.RecordWindow("ghost", "root", "/tmp/ghost.sock", "root.1")
+	if got := m.Get("ghost"); got != nil {
+		t.Fatalf("RecordWindow on an unknown host created one: %v", got)
+	}
+}
+
+func TestRecordWindowAppends(t *testing.T) {
+	m := NewManager()
+	put(m, "host-a", &Info{Session: &gomux.Session{Name: "host-a"}})
+
+	m.RecordWindow("host-a", "alice", "/tmp/a.sock", "alice.1")
+	m.RecordWindow("host-a", "alice", "/tmp/a2.sock", "alice.2")
+
+	info := m.Get("host-a")
+	if info == nil {
+		t.Fatal("Get(host-a) = nil after RecordWindow")
+	}
+	if info.LastUsername != "alice" || info.LastSocket != "/tmp/a2.sock" {
+		t.Fatalf("unexpected bookkeeping: %+v", info)
+	}
+	if len(info.Windows) != 2 || info.Windows[0] != "alice.1" || info.Windows[1] != "alice.2" {
+		t.Fatalf("unexpected Windows: %v", info.Windows)
+	}
+}
+
+func TestRemoveWindow(t *testing.T) {
+	m := NewManager()
+	put(m, "host-a", &Info{
+		Session: &gomux.Session{Name: "host-a"},
+		Windows: []string{"alice.1", "alice.2", "alice.3"},
+	})
+
+	m.RemoveWindow("host-a", "alice.2")
+
+	info := m.Get("host-a")
+	if len(info.Windows) != 2 {
+		t.Fatalf("expected 2 windows left, got %v", info.Windows)
+	}
+	for _, w := range info.Windows {
+		if w == "alice.2" {
+			t.Fatalf("alice.2 should have been removed: %v", info.Windows)
+		}
+	}
+}
+
+func TestRemoveWindowNoSessionOrWindow(t *testing.T) {
+	m := NewManager()
+	// Unknown host: must not panic.
+	m.RemoveWindow("ghost", "root.1")
+
+	put(m, "host-a", &Info{Session: &gomux.Session{Name: "host-a"}, Windows: []string{"alice.1"}})
+	// Unknown window on a known host: leaves Windows untouched.
+	m.RemoveWindow("host-a", "alice.99")
+	if got := m.Get("host-a").Windows; len(got) != 1 || got[0] != "alice.1" {
+		t.Fatalf("unexpected Windows after removing an unknown window: %v", got)
+	}
+}
+
+func TestAllIsASnapshot(t *testing.T) {
+	m := NewManager()
+	put(m, "host-a", &Info{Session: &gomux.Session{Name: "host-a"}})
+	put(m, "host-b", &Info{Session: &gomux.Session{Name: "host-b"}})
+
+	all := m.All()
+	if len(all) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(all))
+	}
+
+	// Mutating the snapshot must not affect the Manager's own map.
+	delete(all, "host-a")
+	if m.Get("host-a") == nil {
+		t.Fatal("deleting from the All() snapshot removed it from the Manager")
+	}
+}
+
+func TestInfoString(t *testing.T) {
+	info := &Info{Session: &gomux.Session{Name: "host-a", NextWindowNumber: 3}}
+	if got, want := info.String(), "host-a (3 windows)"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+}
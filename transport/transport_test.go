@@ -0,0 +1,62 @@
+package transport
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteRequestReadRequestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	want := StreamRequest{Kind: KindUpload, Args: []string{"/etc/passwd", "loot.txt"}}
+
+	if err := WriteRequest(&buf, want); err != nil {
+		t.Fatalf("WriteRequest: %v", err)
+	}
+
+	got, err := ReadRequest(&buf)
+	if err != nil {
+		t.Fatalf("ReadRequest: %v", err)
+	}
+	if got.Kind != want.Kind || len(got.Args) != len(want.Args) {
+		t.Fatalf("ReadRequest() = %+v, want %+v", got, want)
+	}
+	for i := range want.Args {
+		if got.Args[i] != want.Args[i] {
+			t.Fatalf("Args[%d] = %q, want %q", i, got.Args[i], want.Args[i])
+		}
+	}
+}
+
+func TestWriteRequestIsLengthPrefixed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteRequest(&buf, StreamRequest{Kind: KindPing}); err != nil {
+		t.Fatalf("WriteRequest: %v", err)
+	}
+
+	body := buf.Bytes()[4:]
+	var length uint32
+	for i := 0; i < 4; i++ {
+		length = length<<8 | uint32(buf.Bytes()[i])
+	}
+	if int(length) != len(body) {
+		t.Fatalf("length prefix %d doesn't match body length %d", length, len(body))
+	}
+}
+
+func TestReadRequestTruncatedBody(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteRequest(&buf, StreamRequest{Kind: KindExec, Args: []string{"whoami"}}); err != nil {
+		t.Fatalf("WriteRequest: %v", err)
+	}
+
+	truncated := bytes.NewReader(buf.Bytes()[:buf.Len()-2])
+	if _, err := ReadRequest(truncated); err == nil {
+		t.Fatal("expected ReadRequest to fail on a truncated body, got nil error")
+	}
+}
+
+func TestReadRequestEmptyReader(t *testing.T) {
+	if _, err := ReadRequest(bytes.NewReader(nil)); err == nil {
+		t.Fatal("expected ReadRequest to fail reading the length prefix from an empty reader")
+	}
+}
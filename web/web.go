@@ -0,0 +1,261 @@
+// Package web exposes an HTTP+WebSocket viewer so a second operator can
+// watch (or, in read-write mode, drive) an implant's tmux pane from a
+// browser, without attaching to the tmux socket on the box.
+//
+// Server plugs into gorsh-server's cmux HTTP/1.1 match, which (unlike the
+// implant channel) isn't TLS-wrapped, so requireAuth's bearer/basic-auth
+// check and every pane byte it guards travel in cleartext over whatever
+// --host was bound to. Keep that on loopback unless TLS has been terminated
+// in front of it some other way.
+package web
+
+import (
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+//go:embed static
+var staticFS embed.FS
+
+// Session describes one entry in the browser's session picker.
+type Session struct {
+	Host    string   `json:"host"`
+	Windows []string `json:"windows"`
+}
+
+// Lister is implemented by whatever owns the real session state
+// (gorsh-server's session.Manager) so this package doesn't need to import it.
+type Lister interface {
+	ListSessions() []Session
+}
+
+// Server is the live viewer: a pane-output broker plus the HTTP/WebSocket
+// endpoints that expose it.
+type Server struct {
+	Lister   Lister
+	ReadOnly bool
+	// Auth, if non-empty, is required as either a bearer token or the
+	// password half of HTTP basic auth (any username is accepted).
+	Auth string
+
+	subMu sync.Mutex
+	subs  map[string]map[*subscriber]struct{}
+
+	writeMu sync.Mutex
+	writers map[string]io.Writer
+}
+
+type subscriber struct {
+	out chan []byte
+}
+
+// NewServer builds a viewer bound to lister's session list. auth may be
+// empty to disable authentication entirely.
+func NewServer(lister Lister, auth string, readOnly bool) *Server {
+	return &Server{
+		Lister:   lister,
+		Auth:     auth,
+		ReadOnly: readOnly,
+		subs:     make(map[string]map[*subscriber]struct{}),
+		writers:  make(map[string]io.Writer),
+	}
+}
+
+func paneKey(host, window string) string { return host + "/" + window }
+
+// Publish fans pane output out to every browser currently watching
+// host/window. It never blocks on a slow viewer.
+func (s *Server) Publish(host, window string, p []byte) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+
+	for sub := range s.subs[paneKey(host, window)] {
+		select {
+		case sub.out <- append([]byte(nil), p...):
+		default:
+			log.WithFields(log.Fields{"host": host, "window": window}).Warn("web viewer too slow, dropping frame")
+		}
+	}
+}
+
+// RegisterWriter makes host/window's keystroke stream (from a read-write
+// viewer) land on w, which the caller is expected to already serialize
+// against the regular tmux operator's writes.
+func (s *Server) RegisterWriter(host, window string, w io.Writer) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.writers[paneKey(host, window)] = w
+}
+
+// Writer returns the writer installed for host/window by RegisterWriter, or
+// nil if that window has no live shell proxy to write into. Used by the
+// gRPC SendInput RPC to drive a window the same way a read-write browser
+// viewer's keystrokes do.
+func (s *Server) Writer(host, window string) io.Writer {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.writers[paneKey(host, window)]
+}
+
+// UnregisterWriter removes a writer installed by RegisterWriter once its
+// shell has closed.
+func (s *Server) UnregisterWriter(host, window string) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	delete(s.writers, paneKey(host, window))
+}
+
+func (s *Server) subscribe(host, window string) *subscriber {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+
+	k := paneKey(host, window)
+	if s.subs[k] == nil {
+		s.subs[k] = make(map[*subscriber]struct{})
+	}
+	sub := &subscriber{out: make(chan []byte, 64)}
+	s.subs[k][sub] = struct{}{}
+	return sub
+}
+
+func (s *Server) unsubscribe(host, window string, sub *subscriber) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	delete(s.subs[paneKey(host, window)], sub)
+	close(sub.out)
+}
+
+// Subscription is a handle returned by Subscribe, passed back to
+// Unsubscribe once the caller is done reading.
+type Subscription struct {
+	sub          *subscriber
+	host, window string
+}
+
+// C returns the channel of pane output frames for this subscription.
+func (s *Subscription) C() <-chan []byte { return s.sub.out }
+
+// Subscribe lets a non-HTTP caller (e.g. the gRPC Tail RPC) watch
+// host/window the same way a browser viewer does.
+func (s *Server) Subscribe(host, window string) *Subscription {
+	return &Subscription{sub: s.subscribe(host, window), host: host, window: window}
+}
+
+// Unsubscribe releases a Subscription obtained from Subscribe.
+func (s *Server) Unsubscribe(sub *Subscription) {
+	s.unsubscribe(sub.host, sub.window, sub.sub)
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+	window := r.URL.Query().Get("window")
+	if host == "" || window == "" {
+		http.Error(w, "host and window query params are required", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.WithError(err).Warn("websocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	sub := s.Subscribe(host, window)
+	defer s.Unsubscribe(sub)
+
+	go func() {
+		for p := range sub.C() {
+			if err := conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if s.ReadOnly {
+			continue
+		}
+
+		s.writeMu.Lock()
+		dst := s.writers[paneKey(host, window)]
+		s.writeMu.Unlock()
+		if dst != nil {
+			dst.Write(msg)
+		}
+	}
+}
+
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Lister.ListSessions())
+}
+
+// requireAuth enforces Auth (if set) as either a bearer token or a basic
+// auth password before delegating to next.
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	if s.Auth == "" {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != "" &&
+			subtle.ConstantTimeCompare([]byte(token), []byte(s.Auth)) == 1 {
+			next(w, r)
+			return
+		}
+
+		if _, pass, ok := r.BasicAuth(); ok && subtle.ConstantTimeCompare([]byte(pass), []byte(s.Auth)) == 1 {
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="gorsh"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}
+}
+
+// Handler returns the viewer UI, session list API and WebSocket stream as a
+// single http.Handler, for callers that want to mount it on a listener they
+// already own (e.g. a cmux HTTP/1.1 match) instead of calling ListenAndServe.
+func (s *Server) Handler() (http.Handler, error) {
+	static, err := fs.Sub(staticFS, "static")
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", s.requireAuth(http.FileServer(http.FS(static)).ServeHTTP))
+	mux.HandleFunc("/api/sessions", s.requireAuth(s.handleSessions))
+	mux.HandleFunc("/ws", s.requireAuth(s.handleWS))
+	return mux, nil
+}
+
+// ListenAndServe blocks serving the viewer UI, session list API and
+// WebSocket stream on addr (e.g. "127.0.0.1:8080").
+func (s *Server) ListenAndServe(addr string) error {
+	handler, err := s.Handler()
+	if err != nil {
+		return err
+	}
+
+	log.WithField("addr", addr).Info("web viewer started")
+	return http.ListenAndServe(addr, handler)
+}
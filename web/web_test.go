@@ -0,0 +1,100 @@
+package web
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPaneKey(t *testing.T) {
+	if got, want := paneKey("host-a", "root.1"), "host-a/root.1"; got != want {
+		t.Fatalf("paneKey() = %q, want %q", got, want)
+	}
+}
+
+func TestPublishSubscribeUnsubscribe(t *testing.T) {
+	s := NewServer(nil, "", false)
+
+	sub := s.Subscribe("host-a", "root.1")
+	s.Publish("host-a", "root.1", []byte("hello"))
+
+	select {
+	case p := <-sub.C():
+		if !bytes.Equal(p, []byte("hello")) {
+			t.Fatalf("got %q, want %q", p, "hello")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for published frame")
+	}
+
+	// A publish to a different host/window must not be delivered here.
+	s.Publish("host-b", "root.1", []byte("nope"))
+	select {
+	case p := <-sub.C():
+		t.Fatalf("unexpected frame from unrelated publish: %q", p)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.Unsubscribe(sub)
+	if _, ok := <-sub.C(); ok {
+		t.Fatal("expected sub channel to be closed after Unsubscribe")
+	}
+}
+
+func TestPublishDoesNotBlockOnAFullSubscriber(t *testing.T) {
+	s := NewServer(nil, "", false)
+	sub := s.Subscribe("host-a", "root.1")
+	defer s.Unsubscribe(sub)
+
+	// The subscriber channel is buffered at 64 and nobody's draining it;
+	// Publish must drop frames rather than block once it's full.
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			s.Publish("host-a", "root.1", []byte("x"))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked against a full, undrained subscriber")
+	}
+}
+
+type recordingWriter struct {
+	written [][]byte
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.written = append(w.written, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestRegisterWriterAndUnregister(t *testing.T) {
+	s := NewServer(nil, "", false)
+
+	if w := s.Writer("host-a", "root.1"); w != nil {
+		t.Fatalf("Writer() before RegisterWriter = %v, want nil", w)
+	}
+
+	rw := &recordingWriter{}
+	s.RegisterWriter("host-a", "root.1", rw)
+
+	got := s.Writer("host-a", "root.1")
+	if got == nil {
+		t.Fatal("Writer() after RegisterWriter = nil")
+	}
+	if _, err := got.Write([]byte("ls\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if len(rw.written) != 1 || string(rw.written[0]) != "ls\n" {
+		t.Fatalf("unexpected writes recorded: %v", rw.written)
+	}
+
+	s.UnregisterWriter("host-a", "root.1")
+	if w := s.Writer("host-a", "root.1"); w != nil {
+		t.Fatalf("Writer() after UnregisterWriter = %v, want nil", w)
+	}
+}